@@ -0,0 +1,91 @@
+// Package event defines the structured progress events emitted while a
+// Host runs a long operation (Upgrade, Restart, ConfigureAuth), so that
+// callers embedding libmachine can render real progress bars,
+// structured JSON logs, or traces instead of scraping stdout.
+package event
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Phase identifies a stage of a Host's lifecycle.
+type Phase string
+
+const (
+	PhasePreCreate      Phase = "PreCreate"
+	PhaseDriverCreate   Phase = "DriverCreate"
+	PhaseWaitForSSH     Phase = "WaitForSSH"
+	PhaseProvisionOS    Phase = "ProvisionOS"
+	PhaseInstallDocker  Phase = "InstallDocker"
+	PhaseConfigureCerts Phase = "ConfigureCerts"
+	PhaseStartDaemon    Phase = "StartDaemon"
+	PhaseUpgrade        Phase = "Upgrade"
+)
+
+// Status is the outcome of a single Step within a Phase.
+type Status string
+
+const (
+	StatusStarted   Status = "started"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Event is a single, structured progress notification.
+type Event struct {
+	Phase     Phase
+	Step      string
+	Status    Status
+	Detail    string
+	Err       error
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// Sink receives Events.
+type Sink interface {
+	Emit(Event)
+}
+
+// SinkFunc adapts a plain function to a Sink.
+type SinkFunc func(Event)
+
+// Emit implements Sink.
+func (f SinkFunc) Emit(e Event) { f(e) }
+
+// JSONSink returns a Sink that writes each Event to w as a line of JSON.
+// It's a library-side primitive only: wiring it up to a command's
+// --output=json flag is the command layer's job and isn't done by this
+// package.
+func JSONSink(w io.Writer) Sink {
+	enc := json.NewEncoder(w)
+
+	return SinkFunc(func(e Event) {
+		var errMsg string
+		if e.Err != nil {
+			errMsg = e.Err.Error()
+		}
+
+		_ = enc.Encode(jsonEvent{
+			Phase:      string(e.Phase),
+			Step:       e.Step,
+			Status:     string(e.Status),
+			Detail:     e.Detail,
+			Err:        errMsg,
+			StartedAt:  e.StartedAt,
+			DurationMS: e.Duration.Milliseconds(),
+		})
+	})
+}
+
+type jsonEvent struct {
+	Phase      string    `json:"phase"`
+	Step       string    `json:"step"`
+	Status     string    `json:"status"`
+	Detail     string    `json:"detail,omitempty"`
+	Err        string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+}
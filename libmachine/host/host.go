@@ -1,20 +1,24 @@
 package host
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/event"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcndockerclient"
 	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/provision"
 	"github.com/docker/machine/libmachine/provision/pkgaction"
 	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/retry"
 	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/swarm"
@@ -30,9 +34,17 @@ type Host struct {
 	ConfigVersion int
 	Driver        drivers.Driver
 	DriverName    string
-	HostOptions   *Options
-	Name          string
-	RawDriver     []byte `json:"-"`
+	// ProvisionerHint remembers the short OS name (e.g. "ubuntu",
+	// "buildroot", "boot2docker") of the last provisioner detected for
+	// this host, so later operations can skip the full SSH detection
+	// cascade via provision.FastDetect.
+	ProvisionerHint string
+	HostOptions     *Options
+	Name            string
+	RawDriver       []byte `json:"-"`
+
+	provisioner provision.Provisioner
+	events      chan event.Event
 }
 
 type Options struct {
@@ -42,6 +54,10 @@ type Options struct {
 	EngineOptions *engine.Options
 	SwarmOptions  *swarm.Options
 	AuthOptions   *auth.Options
+	// RetryPolicy governs retries of transient driver/SSH failures (e.g. a
+	// flaky VPN link or a cloud API rate limit) across Host operations. A
+	// nil RetryPolicy uses retry.DefaultPolicy.
+	RetryPolicy *retry.Policy
 }
 
 type Metadata struct {
@@ -54,11 +70,70 @@ func ValidateHostName(name string) bool {
 	return validHostNamePattern.MatchString(name)
 }
 
+// retryPolicy returns the retry.Policy to apply to this host's driver/SSH
+// operations: HostOptions.RetryPolicy if set, otherwise retry.DefaultPolicy.
+func (h *Host) retryPolicy() retry.Policy {
+	if h.HostOptions != nil && h.HostOptions.RetryPolicy != nil {
+		return *h.HostOptions.RetryPolicy
+	}
+
+	return retry.DefaultPolicy
+}
+
 func (h *Host) RunSSHCommand(command string) (string, error) {
-	return drivers.RunSSHCommandFromDriver(h.Driver, command)
+	return h.RunSSHCommandContext(context.Background(), command)
+}
+
+// RunSSHCommandContext is RunSSHCommand with a bound on how long the
+// command is allowed to run over SSH. Unlike a plain timeout, ctx
+// cancellation (e.g. Ctrl-C at the CLI) returns ctx.Err() immediately
+// instead of waiting out a hung connection. Transient failures (a dropped
+// VPN link, "connection refused" while the box is still coming up) are
+// retried per h.retryPolicy().
+func (h *Host) RunSSHCommandContext(ctx context.Context, command string) (string, error) {
+	var output string
+
+	err := retry.Do(ctx, h.retryPolicy(), retry.ClassifierFor(h.Driver), func() error {
+		out, err := h.runSSHCommandOnce(ctx, command)
+		if err != nil {
+			return err
+		}
+
+		output = out
+		return nil
+	})
+
+	return output, err
 }
 
+func (h *Host) runSSHCommandOnce(ctx context.Context, command string) (string, error) {
+	client, err := h.createSSHClientOnce()
+	if err != nil {
+		return "", err
+	}
+
+	return ssh.OutputContext(ctx, client, command)
+}
+
+// CreateSSHClient dials an SSH client for h, retrying transient connection
+// failures per h.retryPolicy().
 func (h *Host) CreateSSHClient() (ssh.Client, error) {
+	var client ssh.Client
+
+	err := retry.Do(context.Background(), h.retryPolicy(), retry.ClassifierFor(h.Driver), func() error {
+		c, err := h.createSSHClientOnce()
+		if err != nil {
+			return err
+		}
+
+		client = c
+		return nil
+	})
+
+	return client, err
+}
+
+func (h *Host) createSSHClientOnce() (ssh.Client, error) {
 	addr, err := h.Driver.GetSSHHostname()
 	if err != nil {
 		return ssh.ExternalClient{}, err
@@ -76,53 +151,151 @@ func (h *Host) CreateSSHClient() (ssh.Client, error) {
 	return ssh.NewClient(h.Driver.GetSSHUsername(), addr, port, auth)
 }
 
-func (h *Host) runActionForState(action func() error, desiredState state.State) error {
+// Provisioner returns the provisioner for this host, detecting it on first
+// use and caching the result (along with a hint for next time) so that
+// subsequent calls within the process, and subsequent runs of machine
+// against the same host, don't have to re-run the full SSH detection
+// cascade in provision.DetectProvisioner.
+func (h *Host) Provisioner() (provision.Provisioner, error) {
+	if h.provisioner != nil {
+		return h.provisioner, nil
+	}
+
+	p, err := provision.FastDetect(h.Driver, h.ProvisionerHint)
+	if err != nil {
+		return nil, err
+	}
+
+	h.provisioner = p
+	h.ProvisionerHint = strings.ToLower(p.String())
+
+	return h.provisioner, nil
+}
+
+// Events returns a channel of structured progress Events for operations run
+// on h, in place of the log.Info calls those operations used to make.
+// Callers that don't want to block h's progress should drain it
+// continuously; the channel is buffered, but a full buffer causes events to
+// be dropped rather than stalling the operation.
+func (h *Host) Events() <-chan event.Event {
+	h.initEvents()
+	return h.events
+}
+
+// Sink adapts h's event channel to an event.Sink, for wiring into
+// provision.Pipeline.Sink.
+func (h *Host) Sink() event.Sink {
+	h.initEvents()
+	return event.SinkFunc(h.emitEvent)
+}
+
+func (h *Host) initEvents() {
+	if h.events == nil {
+		h.events = make(chan event.Event, 64)
+	}
+}
+
+func (h *Host) emitEvent(e event.Event) {
+	h.initEvents()
+
+	select {
+	case h.events <- e:
+	default:
+		log.Debugf("dropping event for %s/%s: event channel full", e.Phase, e.Step)
+	}
+}
+
+// emitStep runs fn, reporting its progress as a pair of started/finished
+// Events on h.Events() rather than via log.Info.
+func (h *Host) emitStep(phase event.Phase, step string, fn func() error) error {
+	started := time.Now()
+	h.emitEvent(event.Event{Phase: phase, Step: step, Status: event.StatusStarted, StartedAt: started})
+
+	err := fn()
+
+	status := event.StatusSucceeded
+	if err != nil {
+		status = event.StatusFailed
+	}
+
+	h.emitEvent(event.Event{
+		Phase:     phase,
+		Step:      step,
+		Status:    status,
+		Err:       err,
+		StartedAt: started,
+		Duration:  time.Since(started),
+	})
+
+	return err
+}
+
+func (h *Host) runActionForState(ctx context.Context, action func() error, desiredState state.State) error {
 	if drivers.MachineInState(h.Driver, desiredState)() {
 		return fmt.Errorf("Machine %q is already %s.", h.Name, strings.ToLower(desiredState.String()))
 	}
 
-	if err := action(); err != nil {
+	if err := retry.Do(ctx, h.retryPolicy(), retry.ClassifierFor(h.Driver), action); err != nil {
 		return err
 	}
 
-	return mcnutils.WaitFor(drivers.MachineInState(h.Driver, desiredState))
+	return mcnutils.WaitForContext(ctx, drivers.MachineInState(h.Driver, desiredState))
 }
 
 func (h *Host) Start() error {
-	return h.runActionForState(h.Driver.Start, state.Running)
+	return h.StartContext(context.Background())
+}
+
+// StartContext is Start, bounded by ctx: cancelling ctx stops waiting for
+// the machine to come up (the driver call already issued is not undone).
+func (h *Host) StartContext(ctx context.Context) error {
+	return h.runActionForState(ctx, h.Driver.Start, state.Running)
 }
 
 func (h *Host) Stop() error {
-	return h.runActionForState(h.Driver.Stop, state.Stopped)
+	return h.StopContext(context.Background())
+}
+
+// StopContext is Stop, bounded by ctx.
+func (h *Host) StopContext(ctx context.Context) error {
+	return h.runActionForState(ctx, h.Driver.Stop, state.Stopped)
 }
 
 func (h *Host) Kill() error {
-	return h.runActionForState(h.Driver.Kill, state.Stopped)
+	return h.runActionForState(context.Background(), h.Driver.Kill, state.Stopped)
 }
 
 func (h *Host) Restart() error {
-	if drivers.MachineInState(h.Driver, state.Running)() {
-		if err := h.Stop(); err != nil {
-			return err
+	return h.RestartContext(context.Background())
+}
+
+// RestartContext is Restart, bounded by ctx.
+func (h *Host) RestartContext(ctx context.Context) error {
+	return h.emitStep(event.PhaseStartDaemon, "Restarting", func() error {
+		if drivers.MachineInState(h.Driver, state.Running)() {
+			if err := h.StopContext(ctx); err != nil {
+				return err
+			}
+
+			if err := mcnutils.WaitForContext(ctx, drivers.MachineInState(h.Driver, state.Stopped)); err != nil {
+				return err
+			}
 		}
 
-		if err := mcnutils.WaitFor(drivers.MachineInState(h.Driver, state.Stopped)); err != nil {
+		if err := h.StartContext(ctx); err != nil {
 			return err
 		}
-	}
 
-	if err := h.Start(); err != nil {
-		return err
-	}
-
-	if err := mcnutils.WaitFor(drivers.MachineInState(h.Driver, state.Running)); err != nil {
-		return err
-	}
-
-	return nil
+		return mcnutils.WaitForContext(ctx, drivers.MachineInState(h.Driver, state.Running))
+	})
 }
 
 func (h *Host) Upgrade() error {
+	return h.UpgradeContext(context.Background())
+}
+
+// UpgradeContext is Upgrade, bounded by ctx.
+func (h *Host) UpgradeContext(ctx context.Context) error {
 	machineState, err := h.Driver.GetState()
 	if err != nil {
 		return err
@@ -132,18 +305,24 @@ func (h *Host) Upgrade() error {
 		return errMachineMustBeRunningForUpgrade
 	}
 
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	provisioner, err := h.Provisioner()
 	if err != nil {
 		return err
 	}
 
-	log.Info("Upgrading docker...")
-	if err := provisioner.Package("docker", pkgaction.Upgrade); err != nil {
+	if err := h.emitStep(event.PhaseUpgrade, "Upgrading docker", func() error {
+		return provisioner.Package("docker", pkgaction.Upgrade)
+	}); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	log.Info("Restarting docker...")
-	return provisioner.Service("docker", serviceaction.Restart)
+	return h.emitStep(event.PhaseUpgrade, "Restarting docker", func() error {
+		return provisioner.Service("docker", serviceaction.Restart)
+	})
 }
 
 func (h *Host) URL() (string, error) {
@@ -155,23 +334,42 @@ func (h *Host) AuthOptions() *auth.Options {
 }
 
 func (h *Host) DockerVersion() (string, error) {
-	return mcndockerclient.DockerVersion(h)
+	return h.DockerVersionContext(context.Background())
+}
+
+// DockerVersionContext is DockerVersion, bounded by ctx.
+func (h *Host) DockerVersionContext(ctx context.Context) (string, error) {
+	return mcndockerclient.DockerVersionContext(ctx, h)
 }
 
 func (h *Host) ConfigureAuth() error {
-	provisioner, err := provision.DetectProvisioner(h.Driver)
-	if err != nil {
-		return err
-	}
+	return h.ConfigureAuthContext(context.Background())
+}
 
-	// TODO: This is kind of a hack (or is it?  I'm not really sure until
-	// we have more clearly defined outlook on what the responsibilities
-	// and modularity of the provisioners should be).
-	//
-	// Call provision to re-provision the certs properly.
-	if err := provisioner.Provision(swarm.Options{}, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
+// ConfigureAuthContext is ConfigureAuth, bounded by ctx: cancellation is
+// honored between pipeline steps (see provision.Pipeline.Run).
+func (h *Host) ConfigureAuthContext(ctx context.Context) error {
+	provisioner, err := h.Provisioner()
+	if err != nil {
 		return err
 	}
 
-	return nil
+	// FastDetect/DetectProvisioner build provisioner from nothing but
+	// h.Driver, so a provisioner detected in a fresh process (e.g.
+	// regenerate-certs, which loads the Host from disk) never had its auth
+	// and engine options populated. Those used to only be set as a side
+	// effect of the old Provision() call; set them explicitly instead, now
+	// that the steps below replace that call.
+	provisioner.SetAuthOptions(*h.HostOptions.AuthOptions)
+	provisioner.SetEngineOptions(*h.HostOptions.EngineOptions)
+
+	// The idempotent steps (packages, daemon config, certs, a conditional
+	// restart) are what actually reprovisions certs now; each step's
+	// NeedsRun decides whether it has any work to do, so re-running
+	// ConfigureAuth against an already-configured host is nearly a no-op
+	// instead of always bouncing the docker daemon. Progress is reported
+	// through h.Events() instead of package logging.
+	pipeline := provision.NewPipeline()
+	pipeline.Sink = h.Sink()
+	return pipeline.Run(ctx, provisioner)
 }
@@ -0,0 +1,28 @@
+package mcndockerclient
+
+import "context"
+
+// DockerVersionContext is DockerVersion bounded by ctx: if ctx is done
+// before the host responds, it returns ctx.Err() rather than blocking on
+// an unresponsive daemon.
+func DockerVersionContext(ctx context.Context, host DockerHost) (string, error) {
+	ch := make(chan struct {
+		version string
+		err     error
+	}, 1)
+
+	go func() {
+		version, err := DockerVersion(host)
+		ch <- struct {
+			version string
+			err     error
+		}{version, err}
+	}()
+
+	select {
+	case res := <-ch:
+		return res.version, res.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
@@ -0,0 +1,37 @@
+package mcnutils
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WaitForContext is WaitFor bounded by ctx: it polls f every 3 seconds until
+// it returns true, 60 attempts have been made, or ctx is done, whichever
+// comes first.
+func WaitForContext(ctx context.Context, f func() bool) error {
+	return WaitForWithContext(ctx, f, 60, 3*time.Second)
+}
+
+// WaitForWithContext is WaitForContext with an explicit attempt count and
+// interval between attempts.
+func WaitForWithContext(ctx context.Context, f func() bool, maxAttempts int, interval time.Duration) error {
+	var currentAttempt int
+
+	for {
+		if f() {
+			return nil
+		}
+
+		currentAttempt++
+		if currentAttempt == maxAttempts {
+			return fmt.Errorf("too many retries waiting for condition to be true")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
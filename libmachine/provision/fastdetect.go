@@ -0,0 +1,29 @@
+package provision
+
+import (
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// hintedProvisioners maps a short OS hint (see Host.ProvisionerHint) to the
+// Factory registered for it via Register, so FastDetect can construct the
+// matching provisioner directly instead of running every registered
+// provisioner's Matcher over SSH.
+var hintedProvisioners = map[string]Factory{}
+
+// FastDetect uses hint, a short OS name previously discovered for d (see
+// Host.ProvisionerHint), to construct the matching provisioner directly. It
+// falls back to the full DetectProvisioner cascade when hint is empty, not
+// recognized, or no longer matches the host the hint was saved for (e.g. the
+// box was re-imaged with a different OS since the last run).
+func FastDetect(d drivers.Driver, hint string) (Provisioner, error) {
+	if factory, ok := hintedProvisioners[strings.ToLower(hint)]; ok {
+		p := factory(d)
+		if p.CompatibleWithHost() {
+			return p, nil
+		}
+	}
+
+	return DetectProvisioner(d)
+}
@@ -0,0 +1,108 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// fakeDriver satisfies drivers.Driver by embedding the interface and
+// leaving it nil; these tests never call a driver method directly (the
+// fakeDetectedProvisioner factories below ignore it).
+type fakeDriver struct {
+	drivers.Driver
+}
+
+// fakeDetectedProvisioner satisfies Provisioner by embedding the interface
+// and overriding only the methods FastDetect/DetectProvisioner exercise.
+type fakeDetectedProvisioner struct {
+	Provisioner
+	name       string
+	compatible bool
+}
+
+func (p *fakeDetectedProvisioner) String() string          { return p.name }
+func (p *fakeDetectedProvisioner) CompatibleWithHost() bool { return p.compatible }
+
+// withRegistry swaps the package-level registry and hintedProvisioners for
+// the duration of a test, restoring the originals on cleanup, so tests
+// don't interfere with each other or with the real in-tree registrations.
+func withRegistry(t *testing.T, regs []registration, hints map[string]Factory) {
+	t.Helper()
+
+	origRegistry := registry
+	origHints := hintedProvisioners
+
+	registry = regs
+	hintedProvisioners = hints
+
+	t.Cleanup(func() {
+		registry = origRegistry
+		hintedProvisioners = origHints
+	})
+}
+
+func TestFastDetectUsesHintedProvisionerWhenCompatible(t *testing.T) {
+	factory := func(d drivers.Driver) Provisioner {
+		return &fakeDetectedProvisioner{name: "ubuntu", compatible: true}
+	}
+	withRegistry(t, nil, map[string]Factory{"ubuntu": factory})
+
+	p, err := FastDetect(fakeDriver{}, "ubuntu")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.String() != "ubuntu" {
+		t.Errorf("expected the hinted provisioner, got %q", p.String())
+	}
+}
+
+func TestFastDetectFallsBackWhenHintedProvisionerIsIncompatible(t *testing.T) {
+	hinted := func(d drivers.Driver) Provisioner {
+		return &fakeDetectedProvisioner{name: "ubuntu", compatible: false}
+	}
+	fallback := func(d drivers.Driver) Provisioner {
+		return &fakeDetectedProvisioner{name: "buildroot", compatible: true}
+	}
+
+	withRegistry(t, []registration{
+		{name: "buildroot", matcher: func(drivers.Driver) (bool, error) { return true, nil }, factory: fallback},
+	}, map[string]Factory{"ubuntu": hinted})
+
+	p, err := FastDetect(fakeDriver{}, "ubuntu")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.String() != "buildroot" {
+		t.Errorf("expected to fall back to DetectProvisioner's match, got %q", p.String())
+	}
+}
+
+func TestFastDetectFallsBackWhenHintIsUnknown(t *testing.T) {
+	fallback := func(d drivers.Driver) Provisioner {
+		return &fakeDetectedProvisioner{name: "boot2docker", compatible: true}
+	}
+
+	withRegistry(t, []registration{
+		{name: "boot2docker", matcher: func(drivers.Driver) (bool, error) { return true, nil }, factory: fallback},
+	}, map[string]Factory{})
+
+	p, err := FastDetect(fakeDriver{}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.String() != "boot2docker" {
+		t.Errorf("expected to fall back to DetectProvisioner's match, got %q", p.String())
+	}
+}
+
+func TestDetectProvisionerReturnsErrDetectFailedWhenNothingMatches(t *testing.T) {
+	withRegistry(t, []registration{
+		{name: "ubuntu", matcher: func(drivers.Driver) (bool, error) { return false, nil }, factory: nil},
+	}, map[string]Factory{})
+
+	_, err := DetectProvisioner(fakeDriver{})
+	if _, ok := err.(ErrDetectFailed); !ok {
+		t.Fatalf("expected ErrDetectFailed, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/machine/libmachine/event"
+)
+
+// Step is a single idempotent unit of provisioning work. NeedsRun lets a
+// Pipeline skip steps that are already satisfied on the host, so running
+// provisioning against an already-configured machine is nearly a no-op
+// instead of blindly reinstalling packages and bouncing the docker daemon.
+type Step interface {
+	// Name identifies the step for logging and events.
+	Name() string
+	// Phase classifies the step for event consumers.
+	Phase() event.Phase
+	// NeedsRun reports whether the step still needs to run against p.
+	NeedsRun(p Provisioner) (bool, error)
+	// Run performs the step.
+	Run(p Provisioner) error
+}
+
+// Pipeline runs an ordered sequence of provisioning Steps against a
+// Provisioner, skipping any step whose NeedsRun reports false. If Sink is
+// set, each step's progress is reported through it instead of via package
+// log output.
+type Pipeline struct {
+	Steps []Step
+	Sink  event.Sink
+}
+
+// NewPipeline returns the standard provisioning pipeline: required
+// packages, the docker daemon configuration, TLS certs, and a conditional
+// daemon restart.
+func NewPipeline() *Pipeline {
+	restartNeeded := new(bool)
+
+	return &Pipeline{
+		Steps: []Step{
+			&installPackagesStep{},
+			&configureDaemonStep{changed: restartNeeded},
+			&installCertsStep{changed: restartNeeded},
+			&restartDaemonStep{changed: restartNeeded},
+		},
+	}
+}
+
+// Run executes each step in order against p, skipping steps that report
+// they don't need to run, and stops at the first error or at ctx
+// cancellation.
+func (pl *Pipeline) Run(ctx context.Context, p Provisioner) error {
+	for _, step := range pl.Steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		started := time.Now()
+
+		needsRun, err := step.NeedsRun(p)
+		if err != nil {
+			return fmt.Errorf("checking whether %q needs to run: %s", step.Name(), err)
+		}
+
+		if !needsRun {
+			pl.emit(step, started, nil, "already up to date, skipping")
+			continue
+		}
+
+		err = step.Run(p)
+		pl.emit(step, started, err, "")
+		if err != nil {
+			return fmt.Errorf("%s: %s", step.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+func (pl *Pipeline) emit(step Step, started time.Time, err error, detail string) {
+	if pl.Sink == nil {
+		return
+	}
+
+	status := event.StatusSucceeded
+	if err != nil {
+		status = event.StatusFailed
+	}
+
+	pl.Sink.Emit(event.Event{
+		Phase:     step.Phase(),
+		Step:      step.Name(),
+		Status:    status,
+		Detail:    detail,
+		Err:       err,
+		StartedAt: started,
+		Duration:  time.Since(started),
+	})
+}
@@ -0,0 +1,113 @@
+package provision
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/libmachine/event"
+)
+
+// fakeStep is a Step whose behavior is entirely controlled by its fields,
+// so Pipeline.Run's control flow can be tested without a real Provisioner.
+type fakeStep struct {
+	name     string
+	needsRun bool
+	needsErr error
+	runErr   error
+	onRun    func()
+	ran      bool
+}
+
+func (s *fakeStep) Name() string  { return s.name }
+func (s *fakeStep) Phase() event.Phase { return event.PhaseInstallDocker }
+
+func (s *fakeStep) NeedsRun(Provisioner) (bool, error) {
+	return s.needsRun, s.needsErr
+}
+
+func (s *fakeStep) Run(Provisioner) error {
+	s.ran = true
+	if s.onRun != nil {
+		s.onRun()
+	}
+	return s.runErr
+}
+
+func TestPipelineRunSkipsStepsThatDontNeedToRun(t *testing.T) {
+	skip := &fakeStep{name: "skip", needsRun: false}
+	run := &fakeStep{name: "run", needsRun: true}
+
+	pl := &Pipeline{Steps: []Step{skip, run}}
+	if err := pl.Run(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if skip.ran {
+		t.Error("expected the skip step not to run")
+	}
+	if !run.ran {
+		t.Error("expected the run step to run")
+	}
+}
+
+func TestPipelineRunStopsAtFirstError(t *testing.T) {
+	failing := &fakeStep{name: "failing", needsRun: true, runErr: errors.New("boom")}
+	after := &fakeStep{name: "after", needsRun: true}
+
+	pl := &Pipeline{Steps: []Step{failing, after}}
+	if err := pl.Run(context.Background(), nil); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if after.ran {
+		t.Error("expected the later step not to run after an earlier failure")
+	}
+}
+
+func TestPipelineRunStopsOnCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	step := &fakeStep{name: "step", needsRun: true}
+	pl := &Pipeline{Steps: []Step{step}}
+
+	if err := pl.Run(ctx, nil); err == nil {
+		t.Fatal("expected an error from a cancelled context")
+	}
+	if step.ran {
+		t.Error("expected the step not to run once the context is cancelled")
+	}
+}
+
+func TestPipelineRunPropagatesChangeBetweenSteps(t *testing.T) {
+	changed := false
+
+	configure := &fakeStep{name: "configure", needsRun: true, onRun: func() { changed = true }}
+	// restartDaemonStep's real NeedsRun reads a *bool set by an earlier
+	// step; emulate that coupling with a step whose NeedsRun closes over
+	// the same flag configure's Run sets.
+	restart := &conditionalStep{fakeStep: fakeStep{name: "restart"}, needsRun: func() bool { return changed }}
+
+	pl := &Pipeline{Steps: []Step{configure, restart}}
+
+	if err := pl.Run(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !restart.ran {
+		t.Error("expected the restart step to run because an earlier step changed something")
+	}
+}
+
+// conditionalStep is a Step whose NeedsRun is computed from an external
+// closure, mirroring how restartDaemonStep depends on a *bool set by an
+// earlier step in the real pipeline.
+type conditionalStep struct {
+	fakeStep
+	needsRun func() bool
+}
+
+func (s *conditionalStep) NeedsRun(Provisioner) (bool, error) {
+	return s.needsRun(), nil
+}
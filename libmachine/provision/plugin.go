@@ -0,0 +1,263 @@
+package provision
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/swarm"
+)
+
+// pluginPrefix is the naming convention external provisioner plugins must
+// follow to be discovered on $PATH, mirroring the
+// docker-machine-driver-<name> convention used by drivers/rpc.
+const pluginPrefix = "machine-provisioner-"
+
+// RegisterPlugins scans $PATH for machine-provisioner-<name> binaries and
+// Registers each one, so DetectProvisioner and FastDetect dispatch to it
+// exactly like an in-tree provisioner. Call this once at CLI startup,
+// before any host operation runs.
+func RegisterPlugins() error {
+	for _, name := range discoverPlugins() {
+		name := name
+		Register(name, pluginMatcher(name), func(d drivers.Driver) Provisioner {
+			return &pluginProvisioner{name: name, driver: d}
+		})
+	}
+
+	return nil
+}
+
+func discoverPlugins() []string {
+	seen := map[string]bool{}
+	var names []string
+
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), pluginPrefix) {
+				continue
+			}
+
+			name := strings.TrimPrefix(entry.Name(), pluginPrefix)
+			if seen[name] {
+				continue
+			}
+
+			seen[name] = true
+			names = append(names, name)
+			log.Debugf("found provisioner plugin %q at %s", name, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	return names
+}
+
+func pluginMatcher(name string) Matcher {
+	return func(d drivers.Driver) (bool, error) {
+		p := &pluginProvisioner{name: name, driver: d}
+		var compatible bool
+		if err := p.call("CompatibleWithHost", nil, &compatible); err != nil {
+			return false, err
+		}
+
+		return compatible, nil
+	}
+}
+
+// pluginProvisioner is a Provisioner backed by an out-of-process
+// machine-provisioner-<name> binary: each method call is marshaled to a
+// single-shot invocation of that binary over stdin/stdout, the same way
+// machine's driver plugins work (see drivers/rpc).
+type pluginProvisioner struct {
+	name   string
+	driver drivers.Driver
+}
+
+var _ Provisioner = (*pluginProvisioner)(nil)
+
+// pluginHost carries the driver's SSH connection info down to the
+// subprocess, since the plugin binary has no other way to reach the
+// driver that's running in this process (mirrors the driver state
+// drivers/rpc marshals across its own plugin boundary).
+type pluginHost struct {
+	Hostname string `json:"hostname"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	KeyPath  string `json:"key_path"`
+}
+
+type pluginRequest struct {
+	Method string        `json:"method"`
+	Args   []interface{} `json:"args"`
+	Host   pluginHost    `json:"host"`
+}
+
+type pluginResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+}
+
+func pluginHostFor(d drivers.Driver) (pluginHost, error) {
+	hostname, err := d.GetSSHHostname()
+	if err != nil {
+		return pluginHost{}, err
+	}
+
+	port, err := d.GetSSHPort()
+	if err != nil {
+		return pluginHost{}, err
+	}
+
+	return pluginHost{
+		Hostname: hostname,
+		Port:     port,
+		Username: d.GetSSHUsername(),
+		KeyPath:  d.GetSSHKeyPath(),
+	}, nil
+}
+
+func (p *pluginProvisioner) call(method string, arg interface{}, result interface{}) error {
+	host, err := pluginHostFor(p.driver)
+	if err != nil {
+		return fmt.Errorf("provisioner plugin %q: resolving SSH info: %s", p.name, err)
+	}
+
+	req, err := json.Marshal(pluginRequest{Method: method, Args: []interface{}{arg}, Host: host})
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(pluginPrefix + p.name)
+	cmd.Stdin = bytes.NewReader(req)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("provisioner plugin %q: %s", p.name, err)
+	}
+
+	var resp pluginResponse
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		return fmt.Errorf("provisioner plugin %q: malformed response: %s", p.name, err)
+	}
+
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+
+	if result == nil || resp.Result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(resp.Result, result)
+}
+
+func (p *pluginProvisioner) String() string {
+	return p.name
+}
+
+func (p *pluginProvisioner) CompatibleWithHost() bool {
+	var compatible bool
+	_ = p.call("CompatibleWithHost", nil, &compatible)
+	return compatible
+}
+
+func (p *pluginProvisioner) SSHCommand(args string) (string, error) {
+	var out string
+	err := p.call("SSHCommand", args, &out)
+	return out, err
+}
+
+func (p *pluginProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	return p.call("Package", []interface{}{name, action}, nil)
+}
+
+func (p *pluginProvisioner) Service(name string, action serviceaction.ServiceAction) error {
+	return p.call("Service", []interface{}{name, action}, nil)
+}
+
+func (p *pluginProvisioner) GetDockerOptionsDir() string {
+	var dir string
+	_ = p.call("GetDockerOptionsDir", nil, &dir)
+	return dir
+}
+
+func (p *pluginProvisioner) GetAuthOptions() auth.Options {
+	var opts auth.Options
+	_ = p.call("GetAuthOptions", nil, &opts)
+	return opts
+}
+
+func (p *pluginProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
+	var opts DockerOptions
+	err := p.call("GenerateDockerOptions", dockerPort, &opts)
+	return &opts, err
+}
+
+func (p *pluginProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	return p.call("Provision", []interface{}{swarmOptions, authOptions, engineOptions}, nil)
+}
+
+func (p *pluginProvisioner) GetDriver() drivers.Driver {
+	return p.driver
+}
+
+func (p *pluginProvisioner) Hostname() (string, error) {
+	var hostname string
+	err := p.call("Hostname", nil, &hostname)
+	return hostname, err
+}
+
+func (p *pluginProvisioner) SetHostname(hostname string) error {
+	return p.call("SetHostname", hostname, nil)
+}
+
+func (p *pluginProvisioner) CreateFile(file string, contents string, uid, gid int, perm os.FileMode) error {
+	return p.call("CreateFile", []interface{}{file, contents, uid, gid, perm}, nil)
+}
+
+func (p *pluginProvisioner) SetOsReleaseInfo(info *OsRelease) {
+	_ = p.call("SetOsReleaseInfo", info, nil)
+}
+
+func (p *pluginProvisioner) GetOsReleaseInfo() (*OsRelease, error) {
+	var info OsRelease
+	err := p.call("GetOsReleaseInfo", nil, &info)
+	return &info, err
+}
+
+func (p *pluginProvisioner) GetSwarmOptions() swarm.Options {
+	var opts swarm.Options
+	_ = p.call("GetSwarmOptions", nil, &opts)
+	return opts
+}
+
+// SetAuthOptions and SetEngineOptions let a caller populate a freshly
+// detected Provisioner's auth/engine state without going through the
+// non-idempotent Provision call (see provision.Pipeline).
+func (p *pluginProvisioner) SetAuthOptions(opts auth.Options) {
+	_ = p.call("SetAuthOptions", opts, nil)
+}
+
+func (p *pluginProvisioner) SetEngineOptions(opts engine.Options) {
+	_ = p.call("SetEngineOptions", opts, nil)
+}
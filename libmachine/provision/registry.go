@@ -0,0 +1,80 @@
+package provision
+
+import (
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// Matcher reports whether d's host is the OS a registered provisioner
+// handles, typically by inspecting /etc/os-release over SSH.
+type Matcher func(d drivers.Driver) (bool, error)
+
+// Factory constructs the Provisioner for a driver a Matcher already
+// confirmed compatible.
+type Factory func(d drivers.Driver) Provisioner
+
+type registration struct {
+	name    string
+	matcher Matcher
+	factory Factory
+}
+
+var registry []registration
+
+// ErrDetectFailed is returned by DetectProvisioner when no registered
+// provisioner matches the host.
+type ErrDetectFailed struct{}
+
+func (e ErrDetectFailed) Error() string {
+	return "Unable to detect the provisioner"
+}
+
+// Register adds a provisioner to the set DetectProvisioner and FastDetect
+// consider. name is the short hint persisted as Host.ProvisionerHint (e.g.
+// "ubuntu"); matcher decides whether factory's provisioner applies to a
+// given driver's host. The in-tree provisioners are registered by this
+// file's init(); out-of-process ones are registered by RegisterPlugins.
+func Register(name string, matcher Matcher, factory Factory) {
+	registry = append(registry, registration{name: strings.ToLower(name), matcher: matcher, factory: factory})
+	hintedProvisioners[strings.ToLower(name)] = factory
+}
+
+// DetectProvisioner iterates the provisioners added via Register, in
+// registration order, and returns the first whose matcher reports the
+// driver's host as compatible.
+func DetectProvisioner(d drivers.Driver) (Provisioner, error) {
+	log.Info("Detecting the provisioner...")
+
+	for _, r := range registry {
+		ok, err := r.matcher(d)
+		if err != nil {
+			log.Debugf("skipping provisioner %q: %s", r.name, err)
+			continue
+		}
+
+		if ok {
+			return r.factory(d), nil
+		}
+	}
+
+	return nil, ErrDetectFailed{}
+}
+
+func matchOSRelease(id string) Matcher {
+	return func(d drivers.Driver) (bool, error) {
+		out, err := drivers.RunSSHCommandFromDriver(d, "cat /etc/os-release")
+		if err != nil {
+			return false, err
+		}
+
+		return strings.Contains(strings.ToLower(out), id), nil
+	}
+}
+
+func init() {
+	Register("boot2docker", matchOSRelease("boot2docker"), func(d drivers.Driver) Provisioner { return NewBoot2DockerProvisioner(d) })
+	Register("ubuntu", matchOSRelease("ubuntu"), func(d drivers.Driver) Provisioner { return NewUbuntuProvisioner(d) })
+	Register("buildroot", matchOSRelease("buildroot"), func(d drivers.Driver) Provisioner { return NewBuildrootProvisioner(d) })
+}
@@ -0,0 +1,162 @@
+package provision
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/event"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+)
+
+// installPackagesStep installs the set of packages every provisioned host
+// needs (e.g. docker itself) if they aren't already present.
+type installPackagesStep struct{}
+
+func (s *installPackagesStep) Name() string { return "Installing required packages" }
+
+func (s *installPackagesStep) Phase() event.Phase { return event.PhaseInstallDocker }
+
+func (s *installPackagesStep) NeedsRun(p Provisioner) (bool, error) {
+	_, err := p.SSHCommand("dpkg -s docker-engine >/dev/null 2>&1 || rpm -q docker-engine >/dev/null 2>&1 || which docker")
+	return err != nil, nil
+}
+
+func (s *installPackagesStep) Run(p Provisioner) error {
+	return p.Package("docker", pkgaction.Install)
+}
+
+// configureDaemonStep writes the docker daemon.json for this host, and
+// records on changed whether the on-disk content actually differed so that
+// restartDaemonStep knows whether a restart is warranted.
+type configureDaemonStep struct {
+	changed *bool
+}
+
+func (s *configureDaemonStep) Name() string { return "Configuring docker daemon" }
+
+func (s *configureDaemonStep) Phase() event.Phase { return event.PhaseInstallDocker }
+
+func (s *configureDaemonStep) NeedsRun(p Provisioner) (bool, error) {
+	opts, err := p.GenerateDockerOptions(engine.DefaultPort)
+	if err != nil {
+		return false, err
+	}
+
+	remote, err := p.SSHCommand(fmt.Sprintf("cat %s 2>/dev/null", opts.EngineOptionsPath))
+	if err != nil || strings.TrimSpace(remote) != strings.TrimSpace(opts.EngineOptions) {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (s *configureDaemonStep) Run(p Provisioner) error {
+	opts, err := p.GenerateDockerOptions(engine.DefaultPort)
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.SSHCommand(fmt.Sprintf("printf %%s %s | sudo tee %s", shellQuote(opts.EngineOptions), opts.EngineOptionsPath)); err != nil {
+		return err
+	}
+
+	*s.changed = true
+	return nil
+}
+
+// installCertsStep copies the generated TLS certs onto the host, comparing
+// fingerprints so unchanged certs aren't re-copied and don't trigger a
+// daemon restart.
+type installCertsStep struct {
+	changed *bool
+}
+
+func (s *installCertsStep) Name() string { return "Installing certs" }
+
+func (s *installCertsStep) Phase() event.Phase { return event.PhaseConfigureCerts }
+
+func (s *installCertsStep) NeedsRun(p Provisioner) (bool, error) {
+	auth := p.GetAuthOptions()
+
+	for _, certPath := range []string{auth.CaCertPath, auth.ServerCertPath, auth.ServerKeyPath} {
+		local, err := fileSHA256(certPath)
+		if err != nil {
+			return true, nil
+		}
+
+		remoteDir := p.GetDockerOptionsDir()
+		remote, err := p.SSHCommand(fmt.Sprintf("sha256sum %s/%s 2>/dev/null | cut -d' ' -f1", remoteDir, baseName(certPath)))
+		if err != nil || strings.TrimSpace(remote) != local {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (s *installCertsStep) Run(p Provisioner) error {
+	auth := p.GetAuthOptions()
+	remoteDir := p.GetDockerOptionsDir()
+
+	for _, certPath := range []string{auth.CaCertPath, auth.ServerCertPath, auth.ServerKeyPath} {
+		contents, err := ioutil.ReadFile(certPath)
+		if err != nil {
+			return err
+		}
+
+		remotePath := fmt.Sprintf("%s/%s", remoteDir, baseName(certPath))
+		if _, err := p.SSHCommand(fmt.Sprintf("printf %%s %s | sudo tee %s >/dev/null", shellQuote(string(contents)), remotePath)); err != nil {
+			return err
+		}
+	}
+
+	if err := p.Service("docker", serviceaction.Stop); err != nil {
+		return err
+	}
+
+	*s.changed = true
+	return nil
+}
+
+// restartDaemonStep restarts the docker daemon, but only if an earlier step
+// actually changed something it depends on.
+type restartDaemonStep struct {
+	changed *bool
+}
+
+func (s *restartDaemonStep) Name() string { return "Restarting docker" }
+
+func (s *restartDaemonStep) Phase() event.Phase { return event.PhaseStartDaemon }
+
+func (s *restartDaemonStep) NeedsRun(p Provisioner) (bool, error) {
+	return *s.changed, nil
+}
+
+func (s *restartDaemonStep) Run(p Provisioner) error {
+	return p.Service("docker", serviceaction.Restart)
+}
+
+func fileSHA256(path string) (string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(contents)), nil
+}
+
+func baseName(path string) string {
+	if idx := strings.LastIndex(path, "/"); idx >= 0 {
+		return path[idx+1:]
+	}
+
+	return path
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,254 @@
+package provision
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/provision/pkgaction"
+	"github.com/docker/machine/libmachine/provision/serviceaction"
+)
+
+// fakeProvisioner satisfies Provisioner by embedding the interface and
+// overriding only the methods the provisioning steps actually call.
+type fakeProvisioner struct {
+	Provisioner
+
+	sshCommand func(args string) (string, error)
+	sshCalls   []string
+
+	packageCalls []string
+	packageErr   error
+
+	serviceCalls []serviceaction.ServiceAction
+	serviceErr   error
+
+	dockerOptionsDir string
+	authOptions      auth.Options
+
+	dockerOptions    *DockerOptions
+	dockerOptionsErr error
+}
+
+func (p *fakeProvisioner) SSHCommand(args string) (string, error) {
+	p.sshCalls = append(p.sshCalls, args)
+	if p.sshCommand == nil {
+		return "", nil
+	}
+	return p.sshCommand(args)
+}
+
+func (p *fakeProvisioner) Package(name string, action pkgaction.PackageAction) error {
+	p.packageCalls = append(p.packageCalls, name)
+	return p.packageErr
+}
+
+func (p *fakeProvisioner) Service(name string, action serviceaction.ServiceAction) error {
+	p.serviceCalls = append(p.serviceCalls, action)
+	return p.serviceErr
+}
+
+func (p *fakeProvisioner) GetDockerOptionsDir() string { return p.dockerOptionsDir }
+
+func (p *fakeProvisioner) GetAuthOptions() auth.Options { return p.authOptions }
+
+func (p *fakeProvisioner) GenerateDockerOptions(dockerPort int) (*DockerOptions, error) {
+	return p.dockerOptions, p.dockerOptionsErr
+}
+
+func TestInstallPackagesStepNeedsRunWhenDockerMissing(t *testing.T) {
+	p := &fakeProvisioner{sshCommand: func(string) (string, error) { return "", fmt.Errorf("not found") }}
+
+	needsRun, err := (&installPackagesStep{}).NeedsRun(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !needsRun {
+		t.Error("expected NeedsRun to report true when docker isn't installed")
+	}
+}
+
+func TestInstallPackagesStepSkipsWhenDockerPresent(t *testing.T) {
+	p := &fakeProvisioner{sshCommand: func(string) (string, error) { return "/usr/bin/docker", nil }}
+
+	needsRun, err := (&installPackagesStep{}).NeedsRun(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if needsRun {
+		t.Error("expected NeedsRun to report false when docker is already installed")
+	}
+}
+
+func TestInstallPackagesStepRunInstallsDocker(t *testing.T) {
+	p := &fakeProvisioner{}
+
+	if err := (&installPackagesStep{}).Run(p); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(p.packageCalls) != 1 || p.packageCalls[0] != "docker" {
+		t.Errorf("expected a single Package(docker) call, got %v", p.packageCalls)
+	}
+}
+
+func TestConfigureDaemonStepNeedsRunWhenContentDiffers(t *testing.T) {
+	p := &fakeProvisioner{
+		dockerOptions: &DockerOptions{EngineOptions: "--label foo=bar", EngineOptionsPath: "/etc/docker/daemon.json"},
+		sshCommand:    func(string) (string, error) { return "--label foo=baz", nil },
+	}
+
+	needsRun, err := (&configureDaemonStep{changed: new(bool)}).NeedsRun(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !needsRun {
+		t.Error("expected NeedsRun to report true when the remote daemon config differs")
+	}
+}
+
+func TestConfigureDaemonStepSkipsWhenContentMatches(t *testing.T) {
+	p := &fakeProvisioner{
+		dockerOptions: &DockerOptions{EngineOptions: "--label foo=bar", EngineOptionsPath: "/etc/docker/daemon.json"},
+		sshCommand:    func(string) (string, error) { return "--label foo=bar", nil },
+	}
+
+	needsRun, err := (&configureDaemonStep{changed: new(bool)}).NeedsRun(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if needsRun {
+		t.Error("expected NeedsRun to report false when the remote daemon config already matches")
+	}
+}
+
+func TestConfigureDaemonStepRunWritesOptionsAndMarksChanged(t *testing.T) {
+	changed := new(bool)
+	p := &fakeProvisioner{dockerOptions: &DockerOptions{EngineOptions: "--label foo=bar", EngineOptionsPath: "/etc/docker/daemon.json"}}
+
+	if err := (&configureDaemonStep{changed: changed}).Run(p); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(p.sshCalls) != 1 {
+		t.Fatalf("expected a single SSHCommand call, got %d", len(p.sshCalls))
+	}
+	if !*changed {
+		t.Error("expected the changed flag to be set")
+	}
+}
+
+func TestInstallCertsStepSkipsWhenFingerprintsMatch(t *testing.T) {
+	certPath := writeTempFile(t, "ca-cert-contents")
+	local := sha256Hex(t, certPath)
+
+	p := &fakeProvisioner{
+		authOptions:      auth.Options{CaCertPath: certPath, ServerCertPath: certPath, ServerKeyPath: certPath},
+		dockerOptionsDir: "/etc/docker",
+		sshCommand:       func(string) (string, error) { return local, nil },
+	}
+
+	needsRun, err := (&installCertsStep{changed: new(bool)}).NeedsRun(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if needsRun {
+		t.Error("expected NeedsRun to report false when remote fingerprints already match")
+	}
+}
+
+func TestInstallCertsStepNeedsRunWhenFingerprintsDiffer(t *testing.T) {
+	certPath := writeTempFile(t, "ca-cert-contents")
+
+	p := &fakeProvisioner{
+		authOptions:      auth.Options{CaCertPath: certPath, ServerCertPath: certPath, ServerKeyPath: certPath},
+		dockerOptionsDir: "/etc/docker",
+		sshCommand:       func(string) (string, error) { return "stale-fingerprint", nil },
+	}
+
+	needsRun, err := (&installCertsStep{changed: new(bool)}).NeedsRun(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !needsRun {
+		t.Error("expected NeedsRun to report true when remote fingerprints are stale")
+	}
+}
+
+func TestInstallCertsStepRunCopiesCertsStopsDockerAndMarksChanged(t *testing.T) {
+	certPath := writeTempFile(t, "ca-cert-contents")
+	changed := new(bool)
+
+	p := &fakeProvisioner{
+		authOptions:      auth.Options{CaCertPath: certPath, ServerCertPath: certPath, ServerKeyPath: certPath},
+		dockerOptionsDir: "/etc/docker",
+	}
+
+	if err := (&installCertsStep{changed: changed}).Run(p); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(p.sshCalls) != 3 {
+		t.Errorf("expected 3 SSHCommand calls (one per cert file), got %d", len(p.sshCalls))
+	}
+	if len(p.serviceCalls) != 1 || p.serviceCalls[0] != serviceaction.Stop {
+		t.Errorf("expected a single Service(docker, Stop) call, got %v", p.serviceCalls)
+	}
+	if !*changed {
+		t.Error("expected the changed flag to be set")
+	}
+}
+
+func TestRestartDaemonStepNeedsRunReflectsChangedFlag(t *testing.T) {
+	changed := new(bool)
+	step := &restartDaemonStep{changed: changed}
+
+	if needsRun, _ := step.NeedsRun(&fakeProvisioner{}); needsRun {
+		t.Error("expected NeedsRun to report false when nothing changed")
+	}
+
+	*changed = true
+	if needsRun, _ := step.NeedsRun(&fakeProvisioner{}); !needsRun {
+		t.Error("expected NeedsRun to report true once an earlier step changed something")
+	}
+}
+
+func TestRestartDaemonStepRunRestartsDocker(t *testing.T) {
+	p := &fakeProvisioner{}
+
+	if err := (&restartDaemonStep{changed: new(bool)}).Run(p); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(p.serviceCalls) != 1 || p.serviceCalls[0] != serviceaction.Restart {
+		t.Errorf("expected a single Service(docker, Restart) call, got %v", p.serviceCalls)
+	}
+}
+
+func writeTempFile(t *testing.T, contents string) string {
+	t.Helper()
+
+	f, err := ioutil.TempFile("", "steps-test-")
+	if err != nil {
+		t.Fatalf("creating temp file: %s", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatalf("writing temp file: %s", err)
+	}
+	t.Cleanup(func() { os.Remove(f.Name()) })
+
+	return f.Name()
+}
+
+func sha256Hex(t *testing.T, path string) string {
+	t.Helper()
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading temp file: %s", err)
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(contents))
+}
@@ -0,0 +1,59 @@
+package retry
+
+import (
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// transientPatterns are substrings of error messages that, across the
+// drivers and SSH clients this package has seen, reliably indicate a
+// transient failure rather than a permanent misconfiguration.
+var transientPatterns = []string{
+	"connection refused",
+	"i/o timeout",
+	"no route to host",
+	"connection reset",
+	"eof",
+	"429",
+	"503",
+	"timed out",
+}
+
+// DefaultClassifier matches the well-known transient SSH/network/cloud-API
+// error patterns above.
+func DefaultClassifier(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, pattern := range transientPatterns {
+		if strings.Contains(msg, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TransientClassifier is implemented by drivers that recognize additional,
+// provider-specific transient errors (e.g. a cloud SDK's own rate-limit
+// error type) beyond the patterns DefaultClassifier knows about.
+type TransientClassifier interface {
+	IsTransientError(err error) bool
+}
+
+// ClassifierFor returns the Classifier to use for driver: DefaultClassifier,
+// extended with driver's own IsTransientError when it implements
+// TransientClassifier.
+func ClassifierFor(driver drivers.Driver) Classifier {
+	tc, ok := driver.(TransientClassifier)
+	if !ok {
+		return DefaultClassifier
+	}
+
+	return func(err error) bool {
+		return DefaultClassifier(err) || tc.IsTransientError(err)
+	}
+}
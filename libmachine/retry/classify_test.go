@@ -0,0 +1,56 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+// fakeDriver satisfies drivers.Driver by embedding the interface and
+// leaving it nil, so tests that never exercise driver-specific behavior
+// don't need to implement its full method set.
+type fakeDriver struct {
+	drivers.Driver
+}
+
+type fakeClassifyingDriver struct {
+	drivers.Driver
+	transient bool
+}
+
+func (f fakeClassifyingDriver) IsTransientError(err error) bool { return f.transient }
+
+func TestDefaultClassifierMatchesKnownTransientPatterns(t *testing.T) {
+	cases := []struct {
+		msg  string
+		want bool
+	}{
+		{"dial tcp: connection refused", true},
+		{"dial tcp: i/o timeout", true},
+		{"429 Too Many Requests", true},
+		{"permission denied (publickey)", false},
+	}
+
+	for _, c := range cases {
+		if got := DefaultClassifier(errors.New(c.msg)); got != c.want {
+			t.Errorf("DefaultClassifier(%q) = %v, want %v", c.msg, got, c.want)
+		}
+	}
+}
+
+func TestClassifierForUsesDriverSpecificClassifier(t *testing.T) {
+	classify := ClassifierFor(fakeClassifyingDriver{transient: true})
+
+	if !classify(errors.New("some cloud-specific throttling error")) {
+		t.Error("expected the driver's own IsTransientError to mark this error transient")
+	}
+}
+
+func TestClassifierForFallsBackToDefaultClassifier(t *testing.T) {
+	classify := ClassifierFor(fakeDriver{})
+
+	if !classify(errors.New("connection refused")) {
+		t.Error("expected DefaultClassifier's patterns to still be recognized")
+	}
+}
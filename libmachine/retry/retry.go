@@ -0,0 +1,80 @@
+// Package retry provides a bounded exponential-backoff helper for
+// operations that fail transiently (flaky VPN/cloud links, cloud API rate
+// limits) but should not be retried on permanent errors (bad auth, invalid
+// config).
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Classifier reports whether err is transient and therefore worth
+// retrying. Permanent errors should return false so callers fail fast
+// instead of burning through the retry budget.
+type Classifier func(err error) bool
+
+// Policy bounds how many times, and how long, to retry a transient error.
+type Policy struct {
+	Attempts     int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	Jitter       bool
+}
+
+// DefaultPolicy retries a handful of times with delay doubling from half a
+// second up to 30s, which is enough to ride out a flaky VPN reconnect or a
+// cloud API rate limit without making a single blip fail the whole
+// operation.
+var DefaultPolicy = Policy{
+	Attempts:     5,
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     30 * time.Second,
+	Multiplier:   2,
+	Jitter:       true,
+}
+
+// Do runs fn, retrying per policy for as long as classify reports the
+// error as transient. It gives up immediately on a permanent error or on
+// the last attempt, returning the last error seen, and on ctx cancellation
+// between attempts, returning ctx.Err().
+// A nil classify is treated as "never transient" (fn runs once).
+func Do(ctx context.Context, policy Policy, classify Classifier, fn func() error) error {
+	attempts := policy.Attempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	delay := policy.InitialDelay
+	var err error
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+
+		if attempt == attempts || classify == nil || !classify(err) {
+			return err
+		}
+
+		wait := delay
+		if policy.Jitter {
+			wait = time.Duration(float64(wait) * (0.5 + rand.Float64()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		delay = time.Duration(float64(delay) * policy.Multiplier)
+		if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+			delay = policy.MaxDelay
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,78 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDoRetriesTransientErrorsUntilSuccess(t *testing.T) {
+	attempts := 0
+
+	err := Do(context.Background(), Policy{Attempts: 3, InitialDelay: time.Millisecond, Multiplier: 1}, func(error) bool { return true }, func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestDoStopsImmediatelyOnPermanentError(t *testing.T) {
+	attempts := 0
+	permanent := errors.New("authentication failed")
+
+	err := Do(context.Background(), Policy{Attempts: 5, InitialDelay: time.Millisecond}, func(error) bool { return false }, func() error {
+		attempts++
+		return permanent
+	})
+
+	if err != permanent {
+		t.Fatalf("expected the permanent error to be returned unchanged, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected exactly 1 attempt for a permanent error, got %d", attempts)
+	}
+}
+
+func TestDoGivesUpAfterMaxAttempts(t *testing.T) {
+	attempts := 0
+	transient := errors.New("i/o timeout")
+
+	err := Do(context.Background(), Policy{Attempts: 3, InitialDelay: time.Millisecond, Multiplier: 1}, func(error) bool { return true }, func() error {
+		attempts++
+		return transient
+	})
+
+	if err != transient {
+		t.Fatalf("expected the last transient error to be returned, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected exactly Attempts tries, got %d", attempts)
+	}
+}
+
+func TestDoStopsOnContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+
+	err := Do(ctx, Policy{Attempts: 5, InitialDelay: 10 * time.Millisecond, Multiplier: 1}, func(error) bool { return true }, func() error {
+		attempts++
+		if attempts == 1 {
+			cancel()
+		}
+		return errors.New("connection refused")
+	})
+
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled once ctx is cancelled between retries, got %v", err)
+	}
+}
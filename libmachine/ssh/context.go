@@ -0,0 +1,40 @@
+package ssh
+
+import "context"
+
+// ContextOutputter is implemented by Client implementations that can run a
+// command with native context support (e.g. killing the underlying process
+// on cancellation). Clients that don't implement it still work with
+// OutputContext, just without being able to interrupt an in-flight command.
+type ContextOutputter interface {
+	OutputContext(ctx context.Context, command string) (string, error)
+}
+
+// OutputContext runs command on client, bounded by ctx. If client
+// implements ContextOutputter, that native implementation is used;
+// otherwise OutputContext waits for either the command or ctx, whichever
+// finishes first, and returns ctx.Err() in the latter case without killing
+// the now-orphaned command.
+func OutputContext(ctx context.Context, client Client, command string) (string, error) {
+	if co, ok := client.(ContextOutputter); ok {
+		return co.OutputContext(ctx, command)
+	}
+
+	type result struct {
+		output string
+		err    error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		output, err := client.Output(command)
+		ch <- result{output, err}
+	}()
+
+	select {
+	case res := <-ch:
+		return res.output, res.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}